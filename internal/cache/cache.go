@@ -0,0 +1,10 @@
+// Package cache provides helpers for keying cluster resources the way the
+// various listers expect them.
+package cache
+
+import "fmt"
+
+// FQN returns a fully qualified resource name ie namespace/name.
+func FQN(ns, n string) string {
+	return fmt.Sprintf("%s/%s", ns, n)
+}
@@ -0,0 +1,88 @@
+// Package issues defines the outcome vocabulary sanitizers report against:
+// a severity Level plus a human readable Message, grouped per sub-resource
+// and collected per fully qualified resource name.
+package issues
+
+import "fmt"
+
+// Level tracks a severity gradient from informational to fatal.
+type Level int
+
+// Known severity levels, ordered from least to most severe.
+const (
+	OkLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+// Root is the pseudo sub-resource group used when an issue applies to the
+// resource as a whole rather than to one of its containers/children.
+const Root = "__root__"
+
+// Issue represents a single sanitizer finding.
+type Issue struct {
+	Group   string
+	Level   Level
+	Message string
+}
+
+// New returns a new Issue, optionally formatting Message with args.
+func New(group string, level Level, message string, args ...interface{}) Issue {
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	return Issue{Group: group, Level: level, Message: message}
+}
+
+// Issues represents a collection of Issue.
+type Issues []Issue
+
+// MaxSeverity returns the highest severity level found in this collection.
+func (ii Issues) MaxSeverity() Level {
+	max := OkLevel
+	for _, i := range ii {
+		if i.Level > max {
+			max = i.Level
+		}
+	}
+	return max
+}
+
+// Outcome maps a fully qualified resource name to its collected issues.
+type Outcome map[string]Issues
+
+// Collector accumulates sanitizer findings keyed by fully qualified
+// resource name. It is embedded by every concrete sanitizer.
+type Collector struct {
+	outcome Outcome
+}
+
+// NewCollector returns a new ready to use Collector.
+func NewCollector() *Collector {
+	return &Collector{outcome: Outcome{}}
+}
+
+// Outcome returns the accumulated findings.
+func (c *Collector) Outcome() Outcome {
+	return c.outcome
+}
+
+// InitOutcome ensures fqn has an entry, even if no issues are ever added to
+// it, so resources with a clean bill of health still show up in the report.
+func (c *Collector) InitOutcome(fqn string) {
+	if _, ok := c.outcome[fqn]; !ok {
+		c.outcome[fqn] = Issues{}
+	}
+}
+
+// AddIssue records i against fqn.
+func (c *Collector) AddIssue(fqn string, i Issue) {
+	c.InitOutcome(fqn)
+	c.outcome[fqn] = append(c.outcome[fqn], i)
+}
+
+// MaxSeverity returns the highest severity level recorded for fqn.
+func (c *Collector) MaxSeverity(fqn string) Level {
+	return c.outcome[fqn].MaxSeverity()
+}
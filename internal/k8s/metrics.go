@@ -0,0 +1,12 @@
+package k8s
+
+import "k8s.io/apimachinery/pkg/api/resource"
+
+// Metrics represents a point in time resource usage sample.
+type Metrics struct {
+	CurrentCPU resource.Quantity
+	CurrentMEM resource.Quantity
+}
+
+// ContainerMetrics maps a container name to its current usage.
+type ContainerMetrics map[string]Metrics
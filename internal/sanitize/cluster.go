@@ -0,0 +1,129 @@
+package sanitize
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/derailed/popeye/internal/issues"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// quotaWarnPerc is the percentage of a ResourceQuota's hard limit at which a
+// namespace gets flagged as approaching capacity, before it actually
+// blows the quota.
+const quotaWarnPerc = 80
+
+// NamespaceUsage is the effective (replicas * container requests) resource
+// footprint of every workload sanitized in a namespace.
+type NamespaceUsage struct {
+	CPU  resource.Quantity
+	Mem  resource.Quantity
+	Pods int64
+}
+
+// QuotaLister lists the ResourceQuota in effect per namespace, if any.
+type QuotaLister interface {
+	ListResourceQuotas() map[string]*v1.ResourceQuota
+}
+
+// NodeAllocatableLister exposes the aggregate allocatable capacity across
+// schedulable nodes in the cluster, mirroring the allocatable-resources
+// concept exposed by the Kubelet PodResources API.
+type NodeAllocatableLister interface {
+	ClusterAllocatable() v1.ResourceList
+}
+
+// WorkloadRequestLister sums the effective requests (replicas * container
+// requests) of every Deployment, StatefulSet and DaemonSet sanitized this
+// run, grouped by namespace.
+type WorkloadRequestLister interface {
+	NamespaceRequests() map[string]NamespaceUsage
+}
+
+// ClusterLister aggregates the listers the Cluster sanitizer needs to
+// assess namespace and cluster wide capacity fit.
+type ClusterLister interface {
+	QuotaLister
+	NodeAllocatableLister
+	WorkloadRequestLister
+}
+
+// Cluster sanitizes cluster-wide and per-namespace capacity: are workloads
+// within their ResourceQuota, and does the cluster have enough allocatable
+// capacity to actually schedule what's requested.
+type Cluster struct {
+	*issues.Collector
+	ClusterLister
+}
+
+// NewCluster returns a new Cluster sanitizer.
+func NewCluster(co *issues.Collector, lister ClusterLister) *Cluster {
+	return &Cluster{Collector: co, ClusterLister: lister}
+}
+
+// Sanitize checks every namespace's usage against its ResourceQuota and the
+// cluster's total usage against allocatable node capacity.
+func (c *Cluster) Sanitize(ctx context.Context) error {
+	usage := c.NamespaceRequests()
+	quotas := c.ListResourceQuotas()
+
+	totalCPU := resource.Quantity{Format: resource.DecimalSI}
+	totalMem := resource.Quantity{Format: resource.BinarySI}
+	for ns, u := range usage {
+		c.InitOutcome(ns)
+		totalCPU.Add(u.CPU)
+		totalMem.Add(u.Mem)
+
+		if q, ok := quotas[ns]; ok {
+			c.checkQuota(ns, u, q)
+		}
+	}
+
+	c.checkAllocatable(totalCPU, totalMem)
+
+	return nil
+}
+
+// checkQuota flags a namespace that has exceeded, or is approaching, its
+// ResourceQuota.
+func (c *Cluster) checkQuota(ns string, u NamespaceUsage, q *v1.ResourceQuota) {
+	if hard, ok := q.Spec.Hard[v1.ResourceRequestsCPU]; ok {
+		c.checkQuotaResource(ns, "CPU", u.CPU.MilliValue(), hard.MilliValue(), func(v int64) string {
+			return resource.NewMilliQuantity(v, resource.DecimalSI).String()
+		})
+	}
+	if hard, ok := q.Spec.Hard[v1.ResourceRequestsMemory]; ok {
+		c.checkQuotaResource(ns, "Memory", u.Mem.Value(), hard.Value(), func(v int64) string {
+			return resource.NewQuantity(v, resource.BinarySI).String()
+		})
+	}
+	if hard, ok := q.Spec.Hard[v1.ResourcePods]; ok {
+		c.checkQuotaResource(ns, "Pods", u.Pods, hard.Value(), func(v int64) string {
+			return fmt.Sprintf("%d", v)
+		})
+	}
+}
+
+func (c *Cluster) checkQuotaResource(ns, kind string, used, hard int64, fmtQty func(int64) string) {
+	switch {
+	case used > hard:
+		c.AddIssue(ns, issues.New(issues.Root, issues.ErrorLevel, "%s requests (%s) exceed ResourceQuota (%s)", kind, fmtQty(used), fmtQty(hard)))
+	case hard > 0 && float64(used) >= float64(hard)*quotaWarnPerc/100:
+		c.AddIssue(ns, issues.New(issues.Root, issues.WarnLevel, "%s requests (%s) at %.2f%% of ResourceQuota (%s)", kind, fmtQty(used), float64(used)/float64(hard)*100, fmtQty(hard)))
+	}
+}
+
+// checkAllocatable flags cluster-wide CPU/Memory requests that exceed what
+// schedulable nodes can actually provide, ie the cluster is unschedulable
+// at its currently requested scale.
+func (c *Cluster) checkAllocatable(totalCPU, totalMem resource.Quantity) {
+	alloc := c.ClusterAllocatable()
+
+	if ac, ok := alloc[v1.ResourceCPU]; ok && !ac.IsZero() && totalCPU.MilliValue() > ac.MilliValue() {
+		c.AddIssue(issues.Root, issues.New(issues.Root, issues.WarnLevel, "Cluster-wide CPU requests (%s) exceed allocatable capacity (%s)", totalCPU.String(), ac.String()))
+	}
+	if am, ok := alloc[v1.ResourceMemory]; ok && !am.IsZero() && totalMem.Value() > am.Value() {
+		c.AddIssue(issues.Root, issues.New(issues.Root, issues.WarnLevel, "Cluster-wide Memory requests (%s) exceed allocatable capacity (%s)", totalMem.String(), am.String()))
+	}
+}
@@ -0,0 +1,149 @@
+package sanitize
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/derailed/popeye/internal/issues"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClusterSanitize(t *testing.T) {
+	uu := map[string]struct {
+		lister ClusterLister
+		ns     string
+		issues issues.Issues
+	}{
+		"good": {
+			lister: makeClusterLister(map[string]nsOpts{
+				"default": {cpu: "500m", mem: "500Mi", pods: 5, quotaCPU: "2", quotaMem: "2Gi", quotaPods: 20},
+			}, "4", "4Gi"),
+			ns:     "default",
+			issues: issues.Issues{},
+		},
+		"quotaExceeded": {
+			lister: makeClusterLister(map[string]nsOpts{
+				"default": {cpu: "3", mem: "500Mi", pods: 5, quotaCPU: "2", quotaMem: "2Gi", quotaPods: 20},
+			}, "4", "4Gi"),
+			ns: "default",
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.ErrorLevel, "CPU requests (3) exceed ResourceQuota (2)"),
+			},
+		},
+		"quotaApproaching": {
+			lister: makeClusterLister(map[string]nsOpts{
+				"default": {cpu: "500m", mem: "1700Mi", pods: 5, quotaCPU: "2", quotaMem: "2Gi", quotaPods: 20},
+			}, "4", "4Gi"),
+			ns: "default",
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.WarnLevel, "Memory requests (1700Mi) at 83.01% of ResourceQuota (2Gi)"),
+			},
+		},
+		"quotaZeroBanned": {
+			lister: makeClusterLister(map[string]nsOpts{
+				"default": {cpu: "100m", mem: "500Mi", pods: 5, quotaCPU: "0", quotaMem: "2Gi", quotaPods: 20},
+			}, "4", "4Gi"),
+			ns: "default",
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.ErrorLevel, "CPU requests (100m) exceed ResourceQuota (0)"),
+			},
+		},
+		"quotaZeroUnused": {
+			lister: makeClusterLister(map[string]nsOpts{
+				"default": {cpu: "0", mem: "500Mi", pods: 5, quotaCPU: "0", quotaMem: "2Gi", quotaPods: 20},
+			}, "4", "4Gi"),
+			ns:     "default",
+			issues: issues.Issues{},
+		},
+	}
+
+	for k, u := range uu {
+		t.Run(k, func(t *testing.T) {
+			cl := NewCluster(issues.NewCollector(), u.lister)
+			cl.Sanitize(context.Background())
+
+			assert.Equal(t, u.issues, cl.Outcome()[u.ns])
+		})
+	}
+}
+
+func TestClusterSanitizeAllocatable(t *testing.T) {
+	lister := makeClusterLister(map[string]nsOpts{
+		"default":     {cpu: "3", mem: "3Gi", pods: 5},
+		"kube-system": {cpu: "3", mem: "3Gi", pods: 5},
+	}, "4", "4Gi")
+
+	cl := NewCluster(issues.NewCollector(), lister)
+	cl.Sanitize(context.Background())
+
+	assert.Equal(t, issues.Issues{
+		issues.New(issues.Root, issues.WarnLevel, "Cluster-wide CPU requests (6) exceed allocatable capacity (4)"),
+		issues.New(issues.Root, issues.WarnLevel, "Cluster-wide Memory requests (6Gi) exceed allocatable capacity (4Gi)"),
+	}, cl.Outcome()[issues.Root])
+}
+
+// ----------------------------------------------------------------------------
+// Helpers...
+
+type nsOpts struct {
+	cpu, mem           string
+	pods               int64
+	quotaCPU, quotaMem string
+	quotaPods          int64
+}
+
+type clusterLister struct {
+	nsUsage  map[string]nsOpts
+	allocCPU string
+	allocMem string
+}
+
+func makeClusterLister(nsUsage map[string]nsOpts, allocCPU, allocMem string) *clusterLister {
+	return &clusterLister{nsUsage: nsUsage, allocCPU: allocCPU, allocMem: allocMem}
+}
+
+func (l *clusterLister) NamespaceRequests() map[string]NamespaceUsage {
+	res := make(map[string]NamespaceUsage, len(l.nsUsage))
+	for ns, o := range l.nsUsage {
+		res[ns] = NamespaceUsage{
+			CPU:  toQty(o.cpu),
+			Mem:  toQty(o.mem),
+			Pods: o.pods,
+		}
+	}
+	return res
+}
+
+func (l *clusterLister) ListResourceQuotas() map[string]*v1.ResourceQuota {
+	res := map[string]*v1.ResourceQuota{}
+	for ns, o := range l.nsUsage {
+		if o.quotaCPU == "" && o.quotaMem == "" && o.quotaPods == 0 {
+			continue
+		}
+		hard := v1.ResourceList{}
+		if o.quotaCPU != "" {
+			hard[v1.ResourceRequestsCPU] = toQty(o.quotaCPU)
+		}
+		if o.quotaMem != "" {
+			hard[v1.ResourceRequestsMemory] = toQty(o.quotaMem)
+		}
+		if o.quotaPods != 0 {
+			hard[v1.ResourcePods] = toQty(fmt.Sprintf("%d", o.quotaPods))
+		}
+		res[ns] = &v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "quota"},
+			Spec:       v1.ResourceQuotaSpec{Hard: hard},
+		}
+	}
+	return res
+}
+
+func (l *clusterLister) ClusterAllocatable() v1.ResourceList {
+	return v1.ResourceList{
+		v1.ResourceCPU:    toQty(l.allocCPU),
+		v1.ResourceMemory: toQty(l.allocMem),
+	}
+}
@@ -0,0 +1,357 @@
+// Package sanitize implements the individual resource checks ("sanitizers")
+// that popeye runs against a live cluster. Each sanitizer pairs a narrow
+// Lister interface (so it can be driven against a fake cluster in tests)
+// with a Sanitize method that records Issues against the collector it was
+// built with.
+package sanitize
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/derailed/popeye/internal/cache"
+	"github.com/derailed/popeye/internal/issues"
+	"github.com/derailed/popeye/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	mv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// PopeyeKey namespaces values popeye threads through a sanitize pass via
+// context, such as whether over/under allocation checks are enabled.
+type PopeyeKey string
+
+// HPALister lists HorizontalPodAutoscalers keyed by the fully qualified
+// name of the workload they target (their scaleTargetRef), so a sanitizer
+// can tell whether a given Deployment is autoscaler-managed.
+type HPALister interface {
+	ListHorizontalPodAutoscalers() map[string]*autoscalingv2.HorizontalPodAutoscaler
+}
+
+// PDBLister lists PodDisruptionBudgets so the Deployment sanitizer can
+// assess voluntary-disruption coverage.
+type PDBLister interface {
+	ListPodDisruptionBudgets() map[string]*policyv1.PodDisruptionBudget
+}
+
+// ScaledObject is a minimal mirror of the keda.sh/v1alpha1 ScaledObject CRD
+// fields checkDeployment needs. Popeye has no typed client for KEDA, so
+// implementations populate this off whatever CRD client they use (eg the
+// dynamic client) rather than pulling in the whole ScaledObject schema.
+type ScaledObject struct {
+	Name string
+}
+
+// ScaledObjectLister lists KEDA ScaledObjects keyed by the fully qualified
+// name of the workload they target (their spec.scaleTargetRef), mirroring
+// HPALister, so a sanitizer can tell whether a given Deployment is
+// KEDA-managed.
+type ScaledObjectLister interface {
+	ListScaledObjects() map[string]*ScaledObject
+}
+
+// DeploymentLister lists deployments and the pods/metrics needed to assess
+// their health and utilization.
+type DeploymentLister interface {
+	HPALister
+	PDBLister
+	ScaledObjectLister
+
+	ListDeployments() map[string]*appsv1.Deployment
+	ListPodsBySelector(sel *metav1.LabelSelector) map[string]*v1.Pod
+	ListPodsMetrics() map[string]*mv1beta1.PodMetrics
+	RestartsLimit() int
+	PodCPULimit() float64
+	PodMEMLimit() float64
+	CPUResourceLimits() config.Allocations
+	MEMResourceLimits() config.Allocations
+	CPUManagerPolicy(nodeName string) string
+}
+
+// Deployment sanitizes deployments.
+type Deployment struct {
+	*issues.Collector
+	DeploymentLister
+}
+
+// NewDeployment returns a new Deployment sanitizer.
+func NewDeployment(co *issues.Collector, lister DeploymentLister) *Deployment {
+	return &Deployment{Collector: co, DeploymentLister: lister}
+}
+
+// Sanitize checks all deployments for potential issues.
+func (d *Deployment) Sanitize(ctx context.Context) error {
+	for fqn, dp := range d.ListDeployments() {
+		d.InitOutcome(fqn)
+		d.checkDeployment(fqn, dp)
+		if overAllocs(ctx) {
+			d.checkUtilization(fqn, dp)
+		}
+	}
+
+	return nil
+}
+
+func overAllocs(ctx context.Context) bool {
+	v, ok := ctx.Value(PopeyeKey("OverAllocs")).(bool)
+	return ok && v
+}
+
+func (d *Deployment) checkDeployment(fqn string, dp *appsv1.Deployment) {
+	hpa := d.ListHorizontalPodAutoscalers()[fqn]
+	so := d.ListScaledObjects()[fqn]
+
+	if dp.Spec.Replicas != nil && *dp.Spec.Replicas == 0 {
+		switch {
+		case hpa != nil:
+			d.AddIssue(fqn, issues.New(issues.Root, issues.InfoLevel, "Scaled to zero by HPA %s", hpa.Name))
+		case so != nil:
+			d.AddIssue(fqn, issues.New(issues.Root, issues.InfoLevel, "Scaled to zero by KEDA ScaledObject %s", so.Name))
+		default:
+			d.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "Zero scale detected"))
+		}
+	} else if dp.Status.AvailableReplicas == 0 {
+		d.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "Used? No available replicas found"))
+	}
+
+	if dp.Status.CollisionCount != nil && *dp.Status.CollisionCount > 0 {
+		d.AddIssue(fqn, issues.New(issues.Root, issues.ErrorLevel, "ReplicaSet collisions detected (%d)", *dp.Status.CollisionCount))
+	}
+
+	if hpa != nil {
+		d.checkHPA(fqn, dp, hpa)
+	}
+
+	d.checkPDB(fqn, dp)
+}
+
+// checkPDB flags multi-replica deployments that have no PodDisruptionBudget
+// covering them, a PDB permissive enough to allow zero pods available
+// during a voluntary disruption, or more than one PDB matching the same
+// pods (ambiguous coverage).
+func (d *Deployment) checkPDB(fqn string, dp *appsv1.Deployment) {
+	if dp.Spec.Replicas == nil || *dp.Spec.Replicas < 2 {
+		return
+	}
+
+	sel := labels.Set(dp.Spec.Template.Labels)
+	var matches []*policyv1.PodDisruptionBudget
+	for _, pdb := range d.ListPodDisruptionBudgets() {
+		if pdb.Namespace != dp.Namespace {
+			continue
+		}
+		psel, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || !psel.Matches(sel) {
+			continue
+		}
+		matches = append(matches, pdb)
+	}
+
+	switch len(matches) {
+	case 0:
+		d.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "No PodDisruptionBudget covering this deployment"))
+	case 1:
+		if pdbTooPermissive(matches[0], *dp.Spec.Replicas) {
+			d.AddIssue(fqn, issues.New(issues.Root, issues.ErrorLevel, "PodDisruptionBudget %s permits zero available pods during a voluntary disruption", matches[0].Name))
+		}
+	default:
+		d.AddIssue(fqn, issues.New(issues.Root, issues.InfoLevel, "Multiple PodDisruptionBudgets match this deployment's pods"))
+	}
+}
+
+// pdbTooPermissive reports whether pdb would allow zero pods to remain
+// available during a voluntary disruption of a deployment running reps
+// replicas.
+func pdbTooPermissive(pdb *policyv1.PodDisruptionBudget, reps int32) bool {
+	if pdb.Spec.MinAvailable != nil {
+		if v, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, int(reps), true); err == nil && v <= 0 {
+			return true
+		}
+	}
+	if pdb.Spec.MaxUnavailable != nil {
+		if v, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(reps), true); err == nil && v >= int(reps) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHPA cross-checks a Deployment against the HPA managing it: the HPA
+// can't compute a utilization percentage without CPU/Memory requests, and a
+// Deployment pinned to a fixed replica count outside the HPA's min/max
+// range will never actually be scaled the way the HPA intends.
+func (d *Deployment) checkHPA(fqn string, dp *appsv1.Deployment, hpa *autoscalingv2.HorizontalPodAutoscaler) {
+	if !requestsSet(dp) {
+		d.AddIssue(fqn, issues.New(issues.Root, issues.ErrorLevel, "HorizontalPodAutoscaler %s can't compute utilization: CPU/Memory requests are not set", hpa.Name))
+	}
+
+	if dp.Spec.Replicas == nil || *dp.Spec.Replicas == 0 {
+		return
+	}
+	reps := *dp.Spec.Replicas
+	min := int32(1)
+	if hpa.Spec.MinReplicas != nil {
+		min = *hpa.Spec.MinReplicas
+	}
+	max := hpa.Spec.MaxReplicas
+	if reps < min || reps > max {
+		d.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "Replicas (%d) pinned outside HPA %s range [%d-%d]", reps, hpa.Name, min, max))
+	}
+}
+
+// requestsSet reports whether every container in the pod template has both
+// a CPU and a Memory request, which an HPA needs to compute utilization.
+func requestsSet(dp *appsv1.Deployment) bool {
+	cc := append(append([]v1.Container{}, dp.Spec.Template.Spec.InitContainers...), dp.Spec.Template.Spec.Containers...)
+	for _, co := range cc {
+		if co.Resources.Requests.Cpu().IsZero() || co.Resources.Requests.Memory().IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// checkUtilization flags containers with no resources defined and
+// deployments whose actual load diverges meaningfully from what they
+// requested.
+func (d *Deployment) checkUtilization(fqn string, dp *appsv1.Deployment) {
+	pods := d.ListPodsBySelector(dp.Spec.Selector)
+	mx := d.ListPodsMetrics()
+
+	d.checkCPUManager(fqn, pods)
+
+	var rcpu, rmem, ccpu, cmem resource.Quantity
+	for pfqn, po := range pods {
+		for _, co := range allContainers(po) {
+			if !hasResources(co) {
+				d.AddIssue(fqn, issues.New(co.Name, issues.WarnLevel, "No resources defined"))
+				continue
+			}
+			rcpu.Add(co.Resources.Requests[v1.ResourceCPU])
+			rmem.Add(co.Resources.Requests[v1.ResourceMemory])
+		}
+
+		pm, ok := mx[pfqn]
+		if !ok {
+			pm, ok = mx[cache.FQN(po.Namespace, po.Name)]
+		}
+		if !ok {
+			continue
+		}
+		for _, co := range pm.Containers {
+			ccpu.Add(co.Usage[v1.ResourceCPU])
+			cmem.Add(co.Usage[v1.ResourceMemory])
+		}
+	}
+
+	if rcpu.IsZero() || ccpu.IsZero() {
+		return
+	}
+	if msg, lvl, ok := checkAlloc("CPU", ccpu.MilliValue(), rcpu.MilliValue(), d.CPUResourceLimits()); ok {
+		d.AddIssue(fqn, issues.New(issues.Root, lvl, msg))
+	}
+	if msg, lvl, ok := checkAlloc("Memory", cmem.Value(), rmem.Value(), d.MEMResourceLimits()); ok {
+		d.AddIssue(fqn, issues.New(issues.Root, lvl, msg))
+	}
+}
+
+// checkCPUManager flags Guaranteed-QoS pods that can't benefit from the
+// kubelet's static CPU manager policy: either the policy is "none" so no
+// pod ever gets pinned cores, or the policy is "static" but the pod
+// requests a fractional CPU amount, which the static policy can't pin and
+// silently falls back to the shared pool.
+func (d *Deployment) checkCPUManager(fqn string, pods map[string]*v1.Pod) {
+	var none, static bool
+	for _, po := range pods {
+		policy := d.CPUManagerPolicy(po.Spec.NodeName)
+
+		guaranteed, fractional := true, false
+		cc := allContainers(po)
+		if len(cc) == 0 {
+			guaranteed = false
+		}
+		for _, co := range cc {
+			if !isGuaranteedContainer(co) {
+				guaranteed = false
+				break
+			}
+			if co.Resources.Requests.Cpu().MilliValue()%1000 != 0 {
+				fractional = true
+			}
+		}
+		if !guaranteed {
+			continue
+		}
+
+		switch policy {
+		case "static":
+			if fractional {
+				static = true
+			}
+		case "none":
+			none = true
+		}
+	}
+
+	if static {
+		d.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "Guaranteed pod requests fractional CPU under static cpu-manager-policy; falls back to shared pool"))
+	}
+	if none {
+		d.AddIssue(fqn, issues.New(issues.Root, issues.InfoLevel, "cpu-manager-policy is none; Guaranteed pod gets no core pinning benefit"))
+	}
+}
+
+// isGuaranteedContainer reports whether co qualifies for Guaranteed QoS ie
+// both CPU and memory have equal, non-zero requests and limits.
+func isGuaranteedContainer(co v1.Container) bool {
+	rc, lc := co.Resources.Requests.Cpu(), co.Resources.Limits.Cpu()
+	rm, lm := co.Resources.Requests.Memory(), co.Resources.Limits.Memory()
+	if rc.IsZero() || lc.IsZero() || rm.IsZero() || lm.IsZero() {
+		return false
+	}
+	return rc.Cmp(*lc) == 0 && rm.Cmp(*lm) == 0
+}
+
+// checkAlloc compares an actual usage sample against what was requested and
+// flags a meaningful divergence in either direction.
+func checkAlloc(kind string, current, requested int64, alloc config.Allocations) (string, issues.Level, bool) {
+	if requested == 0 {
+		return "", issues.OkLevel, false
+	}
+
+	if float64(current) >= float64(requested)*(1+float64(alloc.UnderPerc)/100) {
+		pct := float64(current) / float64(requested) * 100
+		return fmt.Sprintf("At current load, %s under allocated. Current:%s vs Requested:%s (%.2f%%)", kind, qtyString(kind, current), qtyString(kind, requested), pct), issues.WarnLevel, true
+	}
+
+	if float64(requested) > float64(current)*(1+float64(alloc.OverPerc)/100) {
+		pct := float64(requested) / float64(current) * 100
+		return fmt.Sprintf("At current load, %s over allocated. Current:%s vs Requested:%s (%.2f%%)", kind, qtyString(kind, current), qtyString(kind, requested), pct), issues.WarnLevel, true
+	}
+
+	return "", issues.OkLevel, false
+}
+
+func qtyString(kind string, v int64) string {
+	if kind == "CPU" {
+		return resource.NewMilliQuantity(v, resource.DecimalSI).String()
+	}
+	return resource.NewQuantity(v, resource.BinarySI).String()
+}
+
+func allContainers(po *v1.Pod) []v1.Container {
+	cc := make([]v1.Container, 0, len(po.Spec.InitContainers)+len(po.Spec.Containers))
+	cc = append(cc, po.Spec.InitContainers...)
+	cc = append(cc, po.Spec.Containers...)
+	return cc
+}
+
+func hasResources(co v1.Container) bool {
+	return len(co.Resources.Requests) > 0
+}
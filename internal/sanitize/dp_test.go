@@ -2,6 +2,7 @@ package sanitize
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/derailed/popeye/internal/cache"
@@ -10,8 +11,11 @@ import (
 	"github.com/derailed/popeye/pkg/config"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	mv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 )
 
@@ -92,6 +96,179 @@ func TestDPSanitize(t *testing.T) {
 				issues.New(issues.Root, issues.ErrorLevel, "ReplicaSet collisions detected (1)"),
 			},
 		},
+		"zeroRepsHPA": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      0,
+				availReps: 1,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "10m",
+					rmem:  "10Mi",
+					lcpu:  "10m",
+					lmem:  "10Mi",
+				},
+				ccpu:    "10m",
+				cmem:    "10Mi",
+				hpaName: "hpa1",
+				hpaMax:  5,
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.InfoLevel, "Scaled to zero by HPA hpa1"),
+			},
+		},
+		"zeroRepsScaledObject": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      0,
+				availReps: 1,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "10m",
+					rmem:  "10Mi",
+					lcpu:  "10m",
+					lmem:  "10Mi",
+				},
+				ccpu:             "10m",
+				cmem:             "10Mi",
+				scaledObjectName: "so1",
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.InfoLevel, "Scaled to zero by KEDA ScaledObject so1"),
+			},
+		},
+		"hpaRequestsMissing": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      2,
+				availReps: 2,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+				},
+				ccpu:    "10m",
+				cmem:    "10Mi",
+				hpaName: "hpa1",
+				hpaMin:  int32Ptr(1),
+				hpaMax:  5,
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.ErrorLevel, "HorizontalPodAutoscaler hpa1 can't compute utilization: CPU/Memory requests are not set"),
+			},
+		},
+		"hpaOutsideRange": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      1,
+				availReps: 1,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "10m",
+					rmem:  "10Mi",
+					lcpu:  "10m",
+					lmem:  "10Mi",
+				},
+				ccpu:    "10m",
+				cmem:    "10Mi",
+				hpaName: "hpa1",
+				hpaMin:  int32Ptr(3),
+				hpaMax:  5,
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.WarnLevel, "Replicas (1) pinned outside HPA hpa1 range [3-5]"),
+			},
+		},
+		"pdbMissing": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      2,
+				availReps: 2,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "10m",
+					rmem:  "10Mi",
+					lcpu:  "10m",
+					lmem:  "10Mi",
+				},
+				ccpu:  "10m",
+				cmem:  "10Mi",
+				noPDB: true,
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.WarnLevel, "No PodDisruptionBudget covering this deployment"),
+			},
+		},
+		"pdbEmptySelectorMatchesAll": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      2,
+				availReps: 2,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "10m",
+					rmem:  "10Mi",
+					lcpu:  "10m",
+					lmem:  "10Mi",
+				},
+				ccpu:             "10m",
+				cmem:             "10Mi",
+				pdbCount:         1,
+				pdbEmptySelector: true,
+			}),
+			issues: issues.Issues{},
+		},
+		"pdbTooPermissive": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      2,
+				availReps: 2,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "10m",
+					rmem:  "10Mi",
+					lcpu:  "10m",
+					lmem:  "10Mi",
+				},
+				ccpu:            "10m",
+				cmem:            "10Mi",
+				pdbCount:        1,
+				pdbMinAvailable: int32Ptr(0),
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.ErrorLevel, "PodDisruptionBudget pdb1 permits zero available pods during a voluntary disruption"),
+			},
+		},
+		"pdbTooPermissiveMaxUnavailable": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      2,
+				availReps: 2,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "10m",
+					rmem:  "10Mi",
+					lcpu:  "10m",
+					lmem:  "10Mi",
+				},
+				ccpu:              "10m",
+				cmem:              "10Mi",
+				pdbCount:          1,
+				pdbMaxUnavailable: int32Ptr(2),
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.ErrorLevel, "PodDisruptionBudget pdb1 permits zero available pods during a voluntary disruption"),
+			},
+		},
+		"pdbOverlap": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:      2,
+				availReps: 2,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "10m",
+					rmem:  "10Mi",
+					lcpu:  "10m",
+					lmem:  "10Mi",
+				},
+				ccpu:     "10m",
+				cmem:     "10Mi",
+				pdbCount: 2,
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.InfoLevel, "Multiple PodDisruptionBudgets match this deployment's pods"),
+			},
+		},
 	}
 
 	for k, u := range uu {
@@ -279,6 +456,98 @@ func TestDPSanitizeUtilization(t *testing.T) {
 				issues.New(issues.Root, issues.WarnLevel, "At current load, Memory over allocated. Current:20Mi vs Requested:60Mi (300.00%)"),
 			},
 		},
+		"cpuManagerStaticFractional": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:       2,
+				availReps:  2,
+				collisions: 0,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "1500m",
+					rmem:  "10Mi",
+					lcpu:  "1500m",
+					lmem:  "10Mi",
+				},
+				ccpu:             "1500m",
+				cmem:             "10Mi",
+				cpuManagerPolicy: "static",
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.WarnLevel, "Guaranteed pod requests fractional CPU under static cpu-manager-policy; falls back to shared pool"),
+			},
+		},
+		"cpuManagerNonePolicy": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:       2,
+				availReps:  2,
+				collisions: 0,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "1000m",
+					rmem:  "10Mi",
+					lcpu:  "1000m",
+					lmem:  "10Mi",
+				},
+				ccpu:             "1000m",
+				cmem:             "10Mi",
+				cpuManagerPolicy: "none",
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.InfoLevel, "cpu-manager-policy is none; Guaranteed pod gets no core pinning benefit"),
+			},
+		},
+		"cpuManagerMixedQoSNotGuaranteed": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:       2,
+				availReps:  2,
+				collisions: 0,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "1000m",
+					rmem:  "10Mi",
+					lcpu:  "1000m",
+					lmem:  "10Mi",
+				},
+				c1Opts: &coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "500m",
+					rmem:  "10Mi",
+					lcpu:  "1000m",
+					lmem:  "10Mi",
+				},
+				ccpu:             "1000m",
+				cmem:             "10Mi",
+				cpuManagerPolicy: "none",
+			}),
+			issues: issues.Issues{},
+		},
+		"cpuManagerInitContainerFractional": {
+			lister: makeDPLister("d1", dpOpts{
+				reps:       2,
+				availReps:  2,
+				collisions: 0,
+				coOpts: coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "1500m",
+					rmem:  "10Mi",
+					lcpu:  "1500m",
+					lmem:  "10Mi",
+				},
+				c1Opts: &coOpts{
+					image: "fred:0.0.1",
+					rcpu:  "1000m",
+					rmem:  "10Mi",
+					lcpu:  "1000m",
+					lmem:  "10Mi",
+				},
+				ccpu:             "1000m",
+				cmem:             "10Mi",
+				cpuManagerPolicy: "static",
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.WarnLevel, "Guaranteed pod requests fractional CPU under static cpu-manager-policy; falls back to shared pool"),
+			},
+		},
 	}
 
 	ctx := context.WithValue(context.Background(), PopeyeKey("OverAllocs"), true)
@@ -298,10 +567,21 @@ func TestDPSanitizeUtilization(t *testing.T) {
 type (
 	dpOpts struct {
 		coOpts
-		reps       int32
-		availReps  int32
-		collisions int32
-		ccpu, cmem string
+		reps              int32
+		availReps         int32
+		collisions        int32
+		ccpu, cmem        string
+		cpuManagerPolicy  string
+		c1Opts            *coOpts
+		hpaName           string
+		hpaMin            *int32
+		hpaMax            int32
+		scaledObjectName  string
+		noPDB             bool
+		pdbCount          int
+		pdbMinAvailable   *int32
+		pdbMaxUnavailable *int32
+		pdbEmptySelector  bool
 	}
 
 	dp struct {
@@ -335,6 +615,7 @@ func (d *dp) ListPodsBySelector(sel *metav1.LabelSelector) map[string]*v1.Pod {
 	return map[string]*v1.Pod{
 		"default/p1": makeFullPod("p1", podOpts{
 			coOpts: d.opts.coOpts,
+			c1Opts: d.opts.c1Opts,
 		}),
 	}
 }
@@ -343,6 +624,81 @@ func (d *dp) RestartsLimit() int {
 	return 10
 }
 
+func (d *dp) CPUManagerPolicy(nodeName string) string {
+	return d.opts.cpuManagerPolicy
+}
+
+func (d *dp) ListHorizontalPodAutoscalers() map[string]*autoscalingv2.HorizontalPodAutoscaler {
+	if d.opts.hpaName == "" {
+		return map[string]*autoscalingv2.HorizontalPodAutoscaler{}
+	}
+	return map[string]*autoscalingv2.HorizontalPodAutoscaler{
+		cache.FQN("default", d.name): {
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      d.opts.hpaName,
+				Namespace: "default",
+			},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				MinReplicas: d.opts.hpaMin,
+				MaxReplicas: d.opts.hpaMax,
+			},
+		},
+	}
+}
+
+func (d *dp) ListScaledObjects() map[string]*ScaledObject {
+	if d.opts.scaledObjectName == "" {
+		return map[string]*ScaledObject{}
+	}
+	return map[string]*ScaledObject{
+		cache.FQN("default", d.name): {Name: d.opts.scaledObjectName},
+	}
+}
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
+
+// ListPodDisruptionBudgets returns a single fully-covering, permissive-safe
+// PDB by default so tests unrelated to PDB coverage don't need to think
+// about it; set opts.noPDB or opts.pdbCount/min/max to exercise the actual
+// PDB checks.
+func (d *dp) ListPodDisruptionBudgets() map[string]*policyv1.PodDisruptionBudget {
+	if d.opts.noPDB {
+		return map[string]*policyv1.PodDisruptionBudget{}
+	}
+
+	count := d.opts.pdbCount
+	if count == 0 {
+		count = 1
+	}
+
+	res := make(map[string]*policyv1.PodDisruptionBudget, count)
+	for i := 0; i < count; i++ {
+		n := fmt.Sprintf("pdb%d", i+1)
+		selector := &metav1.LabelSelector{MatchLabels: map[string]string{"fred": "blee"}}
+		if d.opts.pdbEmptySelector {
+			selector = &metav1.LabelSelector{}
+		}
+		spec := policyv1.PodDisruptionBudgetSpec{
+			Selector: selector,
+		}
+		if d.opts.pdbMinAvailable != nil {
+			v := intstr.FromInt(int(*d.opts.pdbMinAvailable))
+			spec.MinAvailable = &v
+		}
+		if d.opts.pdbMaxUnavailable != nil {
+			v := intstr.FromInt(int(*d.opts.pdbMaxUnavailable))
+			spec.MaxUnavailable = &v
+		}
+		res[cache.FQN("default", n)] = &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: n, Namespace: "default"},
+			Spec:       spec,
+		}
+	}
+	return res
+}
+
 func (d *dp) PodCPULimit() float64 {
 	return 100
 }
@@ -386,6 +742,11 @@ func makeDP(n string, o dpOpts) *appsv1.Deployment {
 				},
 			},
 			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"fred": "blee",
+					},
+				},
 				Spec: v1.PodSpec{
 					InitContainers: []v1.Container{
 						makeContainer("i1", o.coOpts),
@@ -0,0 +1,228 @@
+package sanitize
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/derailed/popeye/internal/issues"
+	v1 "k8s.io/api/core/v1"
+)
+
+// maxMinorSkew is the number of kubelet/kube-proxy minor versions a node is
+// allowed to trail the control plane by before it's flagged as an error.
+// A node within that budget gets no control plane skew issue at all.
+const maxMinorSkew = 2
+
+// maxNodeSkew is the number of kubelet/kube-proxy minor versions nodes in
+// the same pool are allowed to disagree with each other by. This is
+// independent of maxMinorSkew: a pool that's uniformly stale relative to a
+// just-upgraded control plane agrees with itself and passes this check even
+// though it may still fail checkVersionDrift.
+const maxNodeSkew = 1
+
+// legacyTopologyLabels maps deprecated beta topology labels to their GA
+// replacement.
+var legacyTopologyLabels = map[string]string{
+	"failure-domain.beta.kubernetes.io/region": "topology.kubernetes.io/region",
+	"failure-domain.beta.kubernetes.io/zone":   "topology.kubernetes.io/zone",
+}
+
+// standardTopologyLabels must be present on every node for zone-aware
+// scheduling (pod topology spread, volume binding, etc) to work correctly.
+var standardTopologyLabels = []string{
+	"topology.kubernetes.io/region",
+	"topology.kubernetes.io/zone",
+	"node.kubernetes.io/instance-type",
+}
+
+// NodeLister lists cluster nodes and the control plane version they should
+// be tracking.
+type NodeLister interface {
+	ListNodes() map[string]*v1.Node
+	ServerVersion() string
+
+	// CPUManagerPolicy returns the kubelet --cpu-manager-policy in effect on
+	// node n. Implementations typically read this off
+	// node.status.nodeInfo when available, falling back to a configured
+	// cluster-wide hint (config.ClusterHints.CPUManagerPolicy) otherwise.
+	CPUManagerPolicy(n string) string
+}
+
+// Node sanitizes nodes.
+type Node struct {
+	*issues.Collector
+	NodeLister
+}
+
+// NewNode returns a new Node sanitizer.
+func NewNode(co *issues.Collector, lister NodeLister) *Node {
+	return &Node{Collector: co, NodeLister: lister}
+}
+
+// Sanitize checks all nodes for version/kernel drift and missing topology
+// metadata.
+func (n *Node) Sanitize(ctx context.Context) error {
+	nodes := n.ListNodes()
+	for fqn := range nodes {
+		n.InitOutcome(fqn)
+	}
+
+	n.checkVersionDrift(nodes)
+	n.checkNodeSkew(nodes)
+	n.checkKernelDrift(nodes)
+	for fqn, no := range nodes {
+		n.checkTopology(fqn, no)
+	}
+
+	return nil
+}
+
+// checkVersionDrift flags kubelet/kube-proxy versions that have fallen more
+// than maxMinorSkew minors behind the control plane.
+func (n *Node) checkVersionDrift(nodes map[string]*v1.Node) {
+	cpMinor, err := minorVersion(n.ServerVersion())
+	if err != nil {
+		return
+	}
+
+	for fqn, no := range nodes {
+		if minor, err := minorVersion(no.Status.NodeInfo.KubeletVersion); err == nil {
+			if skew := cpMinor - minor; skew > maxMinorSkew {
+				n.AddIssue(fqn, issues.New(issues.Root, issues.ErrorLevel,
+					"Kubelet version %s is %d minors behind control plane (%s)",
+					no.Status.NodeInfo.KubeletVersion, skew, n.ServerVersion()))
+			}
+		}
+		if minor, err := minorVersion(no.Status.NodeInfo.KubeProxyVersion); err == nil {
+			if skew := cpMinor - minor; skew > maxMinorSkew {
+				n.AddIssue(fqn, issues.New(issues.Root, issues.ErrorLevel,
+					"Kube-proxy version %s is %d minors behind control plane (%s)",
+					no.Status.NodeInfo.KubeProxyVersion, skew, n.ServerVersion()))
+			}
+		}
+	}
+}
+
+// checkNodeSkew flags nodes whose kubelet or kube-proxy version has fallen
+// more than maxNodeSkew minors behind the newest version running elsewhere
+// in the pool, catching fleets that disagree with each other even when
+// every node individually still satisfies checkVersionDrift.
+func (n *Node) checkNodeSkew(nodes map[string]*v1.Node) {
+	if len(nodes) < 2 {
+		return
+	}
+
+	n.checkNodeSkewFor(nodes, "Kubelet", func(no *v1.Node) string { return no.Status.NodeInfo.KubeletVersion })
+	n.checkNodeSkewFor(nodes, "Kube-proxy", func(no *v1.Node) string { return no.Status.NodeInfo.KubeProxyVersion })
+}
+
+func (n *Node) checkNodeSkewFor(nodes map[string]*v1.Node, kind string, version func(*v1.Node) string) {
+	minors := make(map[string]int, len(nodes))
+	maxMinor := -1
+	for fqn, no := range nodes {
+		minor, err := minorVersion(version(no))
+		if err != nil {
+			continue
+		}
+		minors[fqn] = minor
+		if minor > maxMinor {
+			maxMinor = minor
+		}
+	}
+
+	for fqn, minor := range minors {
+		if skew := maxMinor - minor; skew > maxNodeSkew {
+			n.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel,
+				"%s version %s is %d minors behind the newest in the nodepool", kind, version(nodes[fqn]), skew))
+		}
+	}
+}
+
+// checkKernelDrift flags nodes whose kernel version or OS image doesn't match
+// the majority of the nodepool, since mixed kernels/images in a pool are a
+// common source of hard to reproduce scheduling issues. Versions and images
+// are compared as exact strings; they are not grouped into families, so two
+// patch releases of the same kernel count as a mismatch.
+func (n *Node) checkKernelDrift(nodes map[string]*v1.Node) {
+	if len(nodes) < 2 {
+		return
+	}
+
+	kernels, images := map[string]int{}, map[string]int{}
+	for _, no := range nodes {
+		kernels[no.Status.NodeInfo.KernelVersion]++
+		images[no.Status.NodeInfo.OSImage]++
+	}
+
+	majorityKernel, majorityImage := majority(kernels), majority(images)
+
+	if len(kernels) > 1 {
+		for fqn, no := range nodes {
+			if no.Status.NodeInfo.KernelVersion == majorityKernel {
+				continue
+			}
+			n.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "Mixed kernel versions detected in nodepool (%s)", no.Status.NodeInfo.KernelVersion))
+		}
+	}
+	if len(images) > 1 {
+		for fqn, no := range nodes {
+			if no.Status.NodeInfo.OSImage == majorityImage {
+				continue
+			}
+			n.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "Mixed OS images detected in nodepool (%s)", no.Status.NodeInfo.OSImage))
+		}
+	}
+}
+
+// majority returns the key with the highest count in counts, breaking ties
+// alphabetically so the result is deterministic regardless of map iteration
+// order.
+func majority(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var best string
+	var max int
+	for _, k := range keys {
+		if counts[k] > max {
+			best, max = k, counts[k]
+		}
+	}
+	return best
+}
+
+// checkTopology flags missing standard topology labels and the presence of
+// legacy beta labels without their GA equivalent.
+func (n *Node) checkTopology(fqn string, no *v1.Node) {
+	for _, l := range standardTopologyLabels {
+		if _, ok := no.Labels[l]; !ok {
+			n.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "Missing topology label %q", l))
+		}
+	}
+
+	for legacy, ga := range legacyTopologyLabels {
+		if _, ok := no.Labels[legacy]; !ok {
+			continue
+		}
+		if _, ok := no.Labels[ga]; !ok {
+			n.AddIssue(fqn, issues.New(issues.Root, issues.WarnLevel, "Legacy label %q present without GA equivalent %q", legacy, ga))
+		}
+	}
+}
+
+// minorVersion extracts the minor component out of a Kubernetes version
+// string such as "v1.28.4" or "v1.28.4-eks-abcd123".
+func minorVersion(v string) (int, error) {
+	v = strings.TrimPrefix(v, "v")
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("malformed version %q", v)
+	}
+	return strconv.Atoi(parts[1])
+}
@@ -0,0 +1,245 @@
+package sanitize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/derailed/popeye/internal/issues"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeSanitize(t *testing.T) {
+	uu := map[string]struct {
+		lister NodeLister
+		issues issues.Issues
+	}{
+		"good": {
+			lister: makeNodeLister("v1.28.4", map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+			}),
+			issues: issues.Issues{},
+		},
+		"versionSkew": {
+			lister: makeNodeLister("v1.30.0", map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.27.1", kubeProxyVersion: "v1.27.1", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.ErrorLevel, "Kubelet version v1.27.1 is 3 minors behind control plane (v1.30.0)"),
+				issues.New(issues.Root, issues.ErrorLevel, "Kube-proxy version v1.27.1 is 3 minors behind control plane (v1.30.0)"),
+			},
+		},
+		"missingTopology": {
+			lister: makeNodeLister("v1.28.4", map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04"},
+			}),
+			issues: issues.Issues{
+				issues.New(issues.Root, issues.WarnLevel, "Missing topology label %q", "topology.kubernetes.io/region"),
+				issues.New(issues.Root, issues.WarnLevel, "Missing topology label %q", "topology.kubernetes.io/zone"),
+				issues.New(issues.Root, issues.WarnLevel, "Missing topology label %q", "node.kubernetes.io/instance-type"),
+			},
+		},
+		"legacyLabel": {
+			lister: makeNodeLister("v1.28.4", map[string]nodeOpts{
+				"n1": {
+					kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04",
+					labels: map[string]string{
+						"topology.kubernetes.io/region":            "us-east-1",
+						"topology.kubernetes.io/zone":              "us-east-1a",
+						"node.kubernetes.io/instance-type":         "m5.large",
+						"failure-domain.beta.kubernetes.io/zone":   "us-east-1a",
+						"failure-domain.beta.kubernetes.io/region": "us-east-1",
+					},
+				},
+			}),
+			issues: issues.Issues{},
+		},
+	}
+
+	for k, u := range uu {
+		t.Run(k, func(t *testing.T) {
+			no := NewNode(issues.NewCollector(), u.lister)
+			no.Sanitize(context.Background())
+
+			assert.ElementsMatch(t, u.issues, no.Outcome()["n1"])
+		})
+	}
+}
+
+func TestNodeSanitizeKernelDrift(t *testing.T) {
+	uu := map[string]struct {
+		nodes  map[string]nodeOpts
+		issues map[string]issues.Issues
+	}{
+		"uniform": {
+			nodes: map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+				"n2": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+			},
+			issues: map[string]issues.Issues{
+				"n1": {},
+				"n2": {},
+			},
+		},
+		"minorityOutlier": {
+			nodes: map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+				"n2": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+				"n3": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.4.0", osImage: "Ubuntu 20.04", labels: fullTopologyLabels()},
+			},
+			issues: map[string]issues.Issues{
+				"n1": {},
+				"n2": {},
+				"n3": {
+					issues.New(issues.Root, issues.WarnLevel, "Mixed kernel versions detected in nodepool (%s)", "5.4.0"),
+					issues.New(issues.Root, issues.WarnLevel, "Mixed OS images detected in nodepool (%s)", "Ubuntu 20.04"),
+				},
+			},
+		},
+		"tieBreaksDeterministically": {
+			nodes: map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+				"n2": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.4.0", osImage: "Ubuntu 20.04", labels: fullTopologyLabels()},
+			},
+			issues: map[string]issues.Issues{
+				"n1": {
+					issues.New(issues.Root, issues.WarnLevel, "Mixed OS images detected in nodepool (%s)", "Ubuntu 22.04"),
+				},
+				"n2": {
+					issues.New(issues.Root, issues.WarnLevel, "Mixed kernel versions detected in nodepool (%s)", "5.4.0"),
+				},
+			},
+		},
+	}
+
+	for k, u := range uu {
+		t.Run(k, func(t *testing.T) {
+			no := NewNode(issues.NewCollector(), makeNodeLister("v1.28.4", u.nodes))
+			no.Sanitize(context.Background())
+
+			for fqn, want := range u.issues {
+				assert.ElementsMatch(t, want, no.Outcome()[fqn])
+			}
+		})
+	}
+}
+
+func TestNodeSanitizeVersionSkew(t *testing.T) {
+	uu := map[string]struct {
+		serverVersion string
+		nodes         map[string]nodeOpts
+		issues        map[string]issues.Issues
+	}{
+		"uniform": {
+			serverVersion: "v1.28.4",
+			nodes: map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+				"n2": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+			},
+			issues: map[string]issues.Issues{
+				"n1": {},
+				"n2": {},
+			},
+		},
+		"laggardWithinControlPlaneBudget": {
+			serverVersion: "v1.28.4",
+			nodes: map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+				"n2": {kubeletVersion: "v1.28.2", kubeProxyVersion: "v1.28.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+				"n3": {kubeletVersion: "v1.26.2", kubeProxyVersion: "v1.26.2", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+			},
+			issues: map[string]issues.Issues{
+				"n1": {},
+				"n2": {},
+				"n3": {
+					issues.New(issues.Root, issues.WarnLevel, "Kubelet version %s is %d minors behind the newest in the nodepool", "v1.26.2", 2),
+					issues.New(issues.Root, issues.WarnLevel, "Kube-proxy version %s is %d minors behind the newest in the nodepool", "v1.26.2", 2),
+				},
+			},
+		},
+		"uniformlyStaleAgreesWithItself": {
+			serverVersion: "v1.30.0",
+			nodes: map[string]nodeOpts{
+				"n1": {kubeletVersion: "v1.27.0", kubeProxyVersion: "v1.27.0", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+				"n2": {kubeletVersion: "v1.27.0", kubeProxyVersion: "v1.27.0", kernel: "5.15.0", osImage: "Ubuntu 22.04", labels: fullTopologyLabels()},
+			},
+			issues: map[string]issues.Issues{
+				"n1": {
+					issues.New(issues.Root, issues.ErrorLevel, "Kubelet version v1.27.0 is 3 minors behind control plane (v1.30.0)"),
+					issues.New(issues.Root, issues.ErrorLevel, "Kube-proxy version v1.27.0 is 3 minors behind control plane (v1.30.0)"),
+				},
+				"n2": {
+					issues.New(issues.Root, issues.ErrorLevel, "Kubelet version v1.27.0 is 3 minors behind control plane (v1.30.0)"),
+					issues.New(issues.Root, issues.ErrorLevel, "Kube-proxy version v1.27.0 is 3 minors behind control plane (v1.30.0)"),
+				},
+			},
+		},
+	}
+
+	for k, u := range uu {
+		t.Run(k, func(t *testing.T) {
+			no := NewNode(issues.NewCollector(), makeNodeLister(u.serverVersion, u.nodes))
+			no.Sanitize(context.Background())
+
+			for fqn, want := range u.issues {
+				assert.ElementsMatch(t, want, no.Outcome()[fqn])
+			}
+		})
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Helpers...
+
+type nodeOpts struct {
+	kubeletVersion, kubeProxyVersion, kernel, osImage string
+	labels                                            map[string]string
+	cpuManagerPolicy                                  string
+}
+
+type nodeLister struct {
+	serverVersion string
+	nodes         map[string]nodeOpts
+}
+
+func makeNodeLister(serverVersion string, nodes map[string]nodeOpts) *nodeLister {
+	return &nodeLister{serverVersion: serverVersion, nodes: nodes}
+}
+
+func (l *nodeLister) ServerVersion() string {
+	return l.serverVersion
+}
+
+func (l *nodeLister) CPUManagerPolicy(n string) string {
+	return l.nodes[n].cpuManagerPolicy
+}
+
+func (l *nodeLister) ListNodes() map[string]*v1.Node {
+	res := make(map[string]*v1.Node, len(l.nodes))
+	for n, o := range l.nodes {
+		res[n] = &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   n,
+				Labels: o.labels,
+			},
+			Status: v1.NodeStatus{
+				NodeInfo: v1.NodeSystemInfo{
+					KubeletVersion:   o.kubeletVersion,
+					KubeProxyVersion: o.kubeProxyVersion,
+					KernelVersion:    o.kernel,
+					OSImage:          o.osImage,
+				},
+			},
+		}
+	}
+	return res
+}
+
+func fullTopologyLabels() map[string]string {
+	return map[string]string{
+		"topology.kubernetes.io/region":    "us-east-1",
+		"topology.kubernetes.io/zone":      "us-east-1a",
+		"node.kubernetes.io/instance-type": "m5.large",
+	}
+}
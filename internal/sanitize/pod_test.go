@@ -0,0 +1,99 @@
+package sanitize
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// ----------------------------------------------------------------------------
+// Helpers shared by the sanitizer tests.
+
+type (
+	coOpts struct {
+		image                  string
+		rcpu, rmem, lcpu, lmem string
+	}
+
+	podOpts struct {
+		coOpts
+		c1Opts *coOpts // when set, overrides resources for the main container (c1) independently of the init container (i1)
+	}
+)
+
+func toQty(s string) resource.Quantity {
+	if s == "" {
+		return resource.Quantity{}
+	}
+	return resource.MustParse(s)
+}
+
+func makeContainer(n string, o coOpts) v1.Container {
+	co := v1.Container{
+		Name:  n,
+		Image: o.image,
+	}
+	if o.rcpu == "" && o.rmem == "" && o.lcpu == "" && o.lmem == "" {
+		return co
+	}
+
+	co.Resources = v1.ResourceRequirements{
+		Requests: v1.ResourceList{},
+		Limits:   v1.ResourceList{},
+	}
+	if o.rcpu != "" {
+		co.Resources.Requests[v1.ResourceCPU] = toQty(o.rcpu)
+	}
+	if o.rmem != "" {
+		co.Resources.Requests[v1.ResourceMemory] = toQty(o.rmem)
+	}
+	if o.lcpu != "" {
+		co.Resources.Limits[v1.ResourceCPU] = toQty(o.lcpu)
+	}
+	if o.lmem != "" {
+		co.Resources.Limits[v1.ResourceMemory] = toQty(o.lmem)
+	}
+
+	return co
+}
+
+func makeFullPod(n string, o podOpts) *v1.Pod {
+	c1Opts := o.coOpts
+	if o.c1Opts != nil {
+		c1Opts = *o.c1Opts
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      n,
+			Namespace: "default",
+		},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{makeContainer("i1", o.coOpts)},
+			Containers:     []v1.Container{makeContainer("c1", c1Opts)},
+		},
+	}
+}
+
+func makeCoMx(n, cpu, mem string) mv1beta1.ContainerMetrics {
+	return mv1beta1.ContainerMetrics{
+		Name: n,
+		Usage: v1.ResourceList{
+			v1.ResourceCPU:    toQty(cpu),
+			v1.ResourceMemory: toQty(mem),
+		},
+	}
+}
+
+func makeMxPod(n, cpu, mem string) *mv1beta1.PodMetrics {
+	return &mv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      n,
+			Namespace: "default",
+		},
+		Containers: []mv1beta1.ContainerMetrics{
+			makeCoMx("i1", cpu, mem),
+			makeCoMx("c1", cpu, mem),
+		},
+	}
+}
@@ -0,0 +1,21 @@
+// Package config holds the configuration types shared by popeye's
+// sanitizers. Values here are sourced from the Spinach config file and/or
+// command line flags and threaded down into individual checks.
+package config
+
+// Allocations tracks the over/under utilization thresholds, expressed as a
+// percentage deviation between a workload's actual resource consumption and
+// what it requested, that a sanitizer should flag.
+type Allocations struct {
+	UnderPerc int `yaml:"underPercUtilization"`
+	OverPerc  int `yaml:"overPercUtilization"`
+}
+
+// ClusterHints carries operator supplied facts about the cluster that
+// can't always be discovered from the API, such as a kubelet setting that
+// isn't exposed on node.status.nodeInfo.
+type ClusterHints struct {
+	// CPUManagerPolicy is used when a node's --cpu-manager-policy can't be
+	// read directly, eg "none" or "static".
+	CPUManagerPolicy string `yaml:"cpuManagerPolicy"`
+}